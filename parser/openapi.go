@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SpecVersion selects the Swagger/OpenAPI document format a Parser should
+// emit. The zero value keeps the historical Swagger 1.2 behaviour.
+// Operation.BuildPathItem and Parser.BuildDocument produce the 3.0 shape for
+// SpecVersion3_0 and the 2.0 shape for every other value, the zero value
+// included.
+type SpecVersion string
+
+const (
+	SpecVersion1_2 SpecVersion = "1.2"
+	SpecVersion2_0 SpecVersion = "2.0"
+	SpecVersion3_0 SpecVersion = "3.0"
+)
+
+// Schema is the minimal JSON Schema representation shared by the 2.0
+// `definitions` section and the 3.0 `components.schemas` section.
+type Schema struct {
+	Ref        string            `json:"$ref,omitempty"`
+	Type       string            `json:"type,omitempty"`
+	Format     string            `json:"format,omitempty"`
+	Items      *Schema           `json:"items,omitempty"`
+	Properties map[string]Schema `json:"properties,omitempty"`
+	Required   []string          `json:"required,omitempty"`
+}
+
+// MediaTypeObject is a single entry of a 3.0 `content` map.
+type MediaTypeObject struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// ResponseObject is a single entry of a 2.0/3.0 `responses` map. Schema is
+// used directly under 2.0 and wrapped in Content for 3.0.
+type ResponseObject struct {
+	Description string                     `json:"description"`
+	Schema      *Schema                     `json:"schema,omitempty"`
+	Content     map[string]MediaTypeObject `json:"content,omitempty"`
+}
+
+// RequestBodyObject is the 3.0 replacement for `paramType: body` parameters.
+type RequestBodyObject struct {
+	Required bool                       `json:"required,omitempty"`
+	Content  map[string]MediaTypeObject `json:"content,omitempty"`
+}
+
+// PathItemOperation is the per-method object nested under
+// `paths[path][method]` in both the 2.0 and 3.0 documents.
+type PathItemOperation struct {
+	OperationId string                    `json:"operationId,omitempty"`
+	Summary     string                    `json:"summary,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Deprecated  bool                      `json:"deprecated,omitempty"`
+	Consumes    []string                  `json:"consumes,omitempty"`
+	Produces    []string                  `json:"produces,omitempty"`
+	Parameters  []Parameter               `json:"parameters,omitempty"`
+	RequestBody *RequestBodyObject        `json:"requestBody,omitempty"`
+	Responses   map[string]ResponseObject `json:"responses"`
+}
+
+// responseSchema builds the schema for a single response entry from that
+// response's own model and array-ness (ResponseMessage.ResponseModel/
+// IsArray/Items, set per-entry in ParseResponseComment) rather than the
+// operation-wide Type/Items, which is last-write-wins across every
+// @Success/@Failure on the same operation and would otherwise put the
+// same $ref on every response.
+func responseSchema(response ResponseMessage) *Schema {
+	if response.ResponseModel == "" {
+		return nil
+	}
+	if response.IsArray {
+		if response.Items.Type != "" {
+			return &Schema{Type: "array", Items: &Schema{Type: response.Items.Type}}
+		}
+		return &Schema{Type: "array", Items: &Schema{Ref: defRef(response.Items.Ref)}}
+	}
+	if IsBasicType(response.ResponseModel) {
+		return nil
+	}
+	return &Schema{Ref: defRef(response.ResponseModel)}
+}
+
+// BuildPathItem translates this Operation into the path-item shape used by
+// Swagger 2.0 and OpenAPI 3.0 documents. route identifies which of the
+// operation's RouterProperties entries is being emitted, since several
+// routes can share the same parameters and responses. Swagger 1.2 output
+// is unaffected and keeps going through the existing Operation JSON tags.
+func (operation *Operation) BuildPathItem(version SpecVersion, route RouteProperties) PathItemOperation {
+	item := PathItemOperation{
+		OperationId: operation.Nickname,
+		Summary:     operation.Summary,
+		Description: operation.Notes,
+		Deprecated:  route.Deprecated,
+		Responses:   make(map[string]ResponseObject, len(operation.ResponseMessages)),
+	}
+
+	parameters := operation.Parameters
+	if version == SpecVersion3_0 {
+		item.Consumes = nil
+		item.Produces = nil
+		parameters = make([]Parameter, 0, len(operation.Parameters))
+		for _, p := range operation.Parameters {
+			if p.ParamType == "body" {
+				item.RequestBody = &RequestBodyObject{
+					Required: p.Required,
+					Content:  mediaTypeMap(operation.Consumes, p.Schema),
+				}
+				continue
+			}
+			parameters = append(parameters, p)
+		}
+	} else {
+		item.Consumes = operation.Consumes
+		item.Produces = operation.Produces
+	}
+	item.Parameters = parameters
+
+	for _, response := range operation.ResponseMessages {
+		code := strconv.Itoa(response.Code)
+		obj := ResponseObject{Description: response.Message}
+		if schema := responseSchema(response); schema != nil {
+			if version == SpecVersion3_0 {
+				obj.Content = mediaTypeMap(operation.Produces, schema)
+			} else {
+				obj.Schema = schema
+			}
+		}
+		item.Responses[code] = obj
+	}
+
+	return item
+}
+
+func mediaTypeMap(mimeTypes []string, schema *Schema) map[string]MediaTypeObject {
+	if schema == nil {
+		return nil
+	}
+	if len(mimeTypes) == 0 {
+		mimeTypes = []string{ContentTypeJson}
+	}
+	content := make(map[string]MediaTypeObject, len(mimeTypes))
+	for _, mime := range mimeTypes {
+		content[mime] = MediaTypeObject{Schema: schema}
+	}
+	return content
+}
+
+// SecurityScheme is a single entry of the top-level `securityDefinitions`
+// (2.0) / `components.securitySchemes` (3.0) section, populated by
+// Parser.ParseSecurityDefinitionComment from root-level
+// @SecurityDefinition.* annotations and referenced by name from an
+// operation's @Security comments (see Operation.ParseSecurityComment).
+type SecurityScheme struct {
+	Type             string            `json:"type"`
+	Name             string            `json:"name,omitempty"`
+	In               string            `json:"in,omitempty"`
+	Flow             string            `json:"flow,omitempty"`
+	AuthorizationURL string            `json:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl,omitempty"`
+	Scopes           map[string]string `json:"scopes,omitempty"`
+}
+
+// ParseSecurityDefinitionComment parses a root/package-level
+// @SecurityDefinition.* annotation and merges it into
+// parser.SecurityDefinitions, which BuildDocument emits as the document's
+// securityDefinitions/components.securitySchemes. Names given to an
+// operation's `@Security <name>` (Operation.ParseSecurityComment) are not
+// checked against this map, and BuildPathItem emits no per-operation
+// security entry.
+//
+//	@SecurityDefinition.apikey ApiKeyAuth header X-API-Key
+//	@SecurityDefinition.basic BasicAuth
+//	@SecurityDefinition.oauth2.implicit OAuth2Implicit https://example.com/oauth/authorize read,write
+func (parser *Parser) ParseSecurityDefinitionComment(commentLine string) error {
+	const prefix = "@SecurityDefinition."
+	commentLine = strings.TrimSpace(commentLine)
+	if !strings.HasPrefix(commentLine, prefix) {
+		return fmt.Errorf("Can not parse security definition comment \"%s\", skipped.", commentLine)
+	}
+
+	fields := strings.Fields(commentLine[len(prefix):])
+	if len(fields) < 2 {
+		return fmt.Errorf("Can not parse security definition comment \"%s\", skipped.", commentLine)
+	}
+	kind, name, args := fields[0], fields[1], fields[2:]
+
+	scheme := SecurityScheme{}
+	switch {
+	case kind == "apikey":
+		if len(args) < 2 {
+			return fmt.Errorf("@SecurityDefinition.apikey %q needs \"<in> <name>\"", name)
+		}
+		scheme.Type = "apiKey"
+		scheme.In = args[0]
+		scheme.Name = args[1]
+	case kind == "basic":
+		scheme.Type = "basic"
+	case strings.HasPrefix(kind, "oauth2."):
+		scheme.Type = "oauth2"
+		scheme.Flow = strings.TrimPrefix(kind, "oauth2.")
+		if len(args) < 1 {
+			return fmt.Errorf("@SecurityDefinition.%s %q needs an authorization URL", kind, name)
+		}
+		scheme.AuthorizationURL = args[0]
+		if len(args) > 1 {
+			scheme.Scopes = make(map[string]string, len(args)-1)
+			for _, scope := range strings.Split(args[1], ",") {
+				if scope = strings.TrimSpace(scope); scope != "" {
+					scheme.Scopes[scope] = ""
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("Unknown security definition kind \"%s\"", kind)
+	}
+
+	if parser.SecurityDefinitions == nil {
+		parser.SecurityDefinitions = make(map[string]SecurityScheme)
+	}
+	parser.SecurityDefinitions[name] = scheme
+	return nil
+}
+
+// modelSchema converts a parsed Model's own Properties/Required (populated
+// by Model.ParseModel) into the Schema placed under
+// definitions/components.schemas, so a $ref into that section resolves to
+// the model's actual shape instead of an empty object.
+func modelSchema(model *Model) Schema {
+	schema := Schema{Type: "object"}
+	if len(model.Required) > 0 {
+		schema.Required = append([]string(nil), model.Required...)
+	}
+	if len(model.Properties) == 0 {
+		return schema
+	}
+	schema.Properties = make(map[string]Schema, len(model.Properties))
+	for name, prop := range model.Properties {
+		propSchema := Schema{Type: prop.Type, Format: prop.Format}
+		if prop.Ref != "" {
+			propSchema = Schema{Ref: defRef(prop.Ref)}
+		}
+		if prop.Items != nil {
+			items := &Schema{Type: prop.Items.Type}
+			if prop.Items.Ref != "" {
+				items = &Schema{Ref: defRef(prop.Items.Ref)}
+			}
+			propSchema.Items = items
+		}
+		schema.Properties[name] = propSchema
+	}
+	return schema
+}
+
+// BuildDocument assembles the Swagger 2.0/OpenAPI 3.0 `paths` section (one
+// entry per RouteProperties of every operation) plus the shared
+// `definitions`/`components.schemas` section built from every model the
+// operations collected along the way. Parser.SpecVersion selects which
+// shape is produced: SpecVersion3_0 gives the 3.0 shape, and any other
+// value, the zero value included, gives the 2.0 shape.
+func (parser *Parser) BuildDocument(operations []*Operation) map[string]interface{} {
+	paths := make(map[string]map[string]PathItemOperation)
+	definitions := make(map[string]Schema)
+
+	for _, operation := range operations {
+		for _, route := range operation.RouterProperties {
+			if paths[route.Path] == nil {
+				paths[route.Path] = make(map[string]PathItemOperation)
+			}
+			paths[route.Path][strings.ToLower(route.HTTPMethod)] = operation.BuildPathItem(parser.SpecVersion, route)
+		}
+		for _, model := range operation.models {
+			// The map key is the raw model id; defRef escapes it when
+			// building the $ref string, per RFC 6901 (a JSON object key
+			// may contain "/" - only the pointer segment referencing it
+			// needs escaping).
+			definitions[model.Id] = modelSchema(model)
+		}
+	}
+
+	document := map[string]interface{}{"paths": paths}
+	if parser.SpecVersion == SpecVersion3_0 {
+		components := map[string]interface{}{"schemas": definitions}
+		if len(parser.SecurityDefinitions) > 0 {
+			components["securitySchemes"] = parser.SecurityDefinitions
+		}
+		document["components"] = components
+	} else {
+		document["definitions"] = definitions
+		if len(parser.SecurityDefinitions) > 0 {
+			document["securityDefinitions"] = parser.SecurityDefinitions
+		}
+	}
+	return document
+}
@@ -1,16 +1,21 @@
 package parser
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/build"
+	"io/ioutil"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+
+	"gopkg.in/yaml.v2"
 )
 
 type Operation struct {
-	HttpMethod       string            `json:"httpMethod"`
 	Nickname         string            `json:"nickname"`
 	Type             string            `json:"type"`
 	Items            OperationItems    `json:"items,omitempty"`
@@ -22,16 +27,35 @@ type Operation struct {
 	Produces         []string          `json:"produces,omitempty"`
 	Authorizations   []Authorization   `json:"authorizations,omitempty"`
 	Protocols        []Protocol        `json:"protocols,omitempty"`
-	Path             string            `json:`
+	// HttpMethod and Path mirror the first entry of RouterProperties, kept
+	// so the Swagger 1.2 emitter (which has no notion of multiple routes
+	// per operation) keeps seeing a method/path through JSON the same way
+	// it did before RouterProperties existed.
+	HttpMethod       string            `json:"httpMethod,omitempty"`
+	Path             string            `json:"path,omitempty"`
+	RouterProperties []RouteProperties `json:"-"`
+	Tags             []string          `json:"tags,omitempty"`
 	parser           *Parser
 	models           []*Model
 	packageName      string
+	deprecated       bool
+	imports          map[string]string
 }
 type OperationItems struct {
 	Ref  string `json:"$ref,omitempty"`
 	Type string `json:"type,omitempty"`
 }
 
+// RouteProperties is one (method, path) pair a handler was registered
+// under. Operation.RouterProperties holds one entry per @router comment so
+// a single handler documented under several routes shares its parameters
+// and responses across all of them.
+type RouteProperties struct {
+	HTTPMethod string
+	Path       string
+	Deprecated bool
+}
+
 func NewOperation(p *Parser, packageName string) *Operation {
 	return &Operation{
 		parser:      p,
@@ -45,10 +69,90 @@ func (operation *Operation) SetItemsType(itemsType string) {
 	if IsBasicType(itemsType) {
 		operation.Items.Type = itemsType
 	} else {
-		operation.Items.Ref = itemsType
+		operation.Items.Ref = operation.qualifyModelType(itemsType)
+	}
+}
+
+// SetPackageImports makes the operation aware of the enclosing file's
+// import list, so `{object} pkg.Type` references in @Param/@Success/
+// @Failure resolve to a fully-qualified import path instead of colliding
+// on the short package name alone when two packages share it. The alias
+// an unqualified import is referenced by in source is the package's own
+// declared name, which doesn't always match the last path segment (e.g.
+// "gopkg.in/yaml.v2" declares package "yaml"), so it's resolved with
+// go/build rather than guessed from the path.
+func (operation *Operation) SetPackageImports(imports []*ast.ImportSpec) {
+	if len(imports) == 0 {
+		return
+	}
+	operation.imports = make(map[string]string, len(imports))
+	for _, imp := range imports {
+		path := strings.Trim(imp.Path.Value, `"`)
+		operation.imports[packageAlias(imp, path)] = path
 	}
 }
 
+// packageAlias returns the identifier this import is referenced by in
+// source: the explicit alias if the import declares one, otherwise the
+// target package's own declared name as resolved by go/build, falling
+// back to the last path segment only if that package can't be located.
+func packageAlias(imp *ast.ImportSpec, path string) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+	if pkg, err := build.Import(path, "", build.FindOnly); err == nil {
+		if name, err := packageNameInDir(pkg.Dir); err == nil && name != "" {
+			return name
+		}
+	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// packageNameInDir reads the package name actually declared by the .go
+// files in dir, via go/build, which is how packageAlias resolves an
+// import's alias once it has located the import's directory.
+func packageNameInDir(dir string) (string, error) {
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return "", err
+	}
+	return pkg.Name, nil
+}
+
+// qualifyModelType resolves a `pkg.Type` reference to `importpath.Type`
+// using the current file's import list (see SetPackageImports), falling
+// back to the bare reference so model.ParseModel's existing
+// CurrentPackage fallback still applies when the prefix is unknown.
+func (operation *Operation) qualifyModelType(ref string) string {
+	dot := strings.LastIndex(ref, ".")
+	if dot == -1 || operation.imports == nil {
+		return ref
+	}
+	if path, ok := operation.imports[ref[:dot]]; ok {
+		return path + ref[dot:]
+	}
+	return ref
+}
+
+// defRef builds a `$ref` into the definitions/components.schemas section
+// for a (possibly import-path-qualified) model id. JSON Pointer (RFC 6901)
+// treats "/" as a path separator, so a raw import path like
+// "github.com/foo/model.User" can't appear as-is after "#/definitions/" -
+// it would be read as nested lookups instead of a single key - and must be
+// escaped with jsonPointerEscape first.
+func defRef(modelID string) string {
+	return "#/definitions/" + jsonPointerEscape(modelID)
+}
+
+// jsonPointerEscape escapes a key for use inside a JSON Pointer fragment,
+// per RFC 6901 (~ -> ~0, / -> ~1). The order matters: ~ must be escaped
+// first so the ~1 introduced for "/" isn't re-escaped.
+func jsonPointerEscape(key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	key = strings.ReplaceAll(key, "/", "~1")
+	return key
+}
+
 func (operation *Operation) ParseComment(commentList *ast.CommentGroup) error {
 	if commentList != nil && commentList.List != nil {
 		for _, comment := range commentList.List {
@@ -80,67 +184,232 @@ func (operation *Operation) ParseComment(commentList *ast.CommentGroup) error {
 				if err := operation.ParseAcceptComment(commentLine); err != nil {
 					return err
 				}
+			} else if strings.HasPrefix(commentLine, "@Produce") {
+				if err := operation.ParseProduceComment(commentLine); err != nil {
+					return err
+				}
+			} else if strings.HasPrefix(commentLine, "@Tags") {
+				tags := strings.TrimSpace(commentLine[len("@Tags"):])
+				for _, tag := range strings.Split(tags, ",") {
+					if tag = strings.TrimSpace(tag); tag != "" {
+						operation.Tags = append(operation.Tags, tag)
+					}
+				}
+			} else if strings.HasPrefix(commentLine, "@ID") {
+				operation.Nickname = strings.TrimSpace(commentLine[len("@ID"):])
+			} else if strings.HasPrefix(commentLine, "@SecurityDefinition.") {
+				if err := operation.parser.ParseSecurityDefinitionComment(commentLine); err != nil {
+					return err
+				}
+			} else if strings.HasPrefix(commentLine, "@Security") {
+				if err := operation.ParseSecurityComment(commentLine); err != nil {
+					return err
+				}
+			} else if strings.HasPrefix(commentLine, "@x-codeSamples") {
+				if len(operation.ResponseMessages) == 0 {
+					return fmt.Errorf("@x-codeSamples must follow an @Success/@Failure response")
+				}
+				last := &operation.ResponseMessages[len(operation.ResponseMessages)-1]
+				if err := operation.loadResponseExampleFile(last); err != nil {
+					return err
+				}
+			} else if strings.HasPrefix(commentLine, "@Deprecated") {
+				operation.deprecated = true
+				for i := range operation.RouterProperties {
+					operation.RouterProperties[i].Deprecated = true
+				}
 			}
 		}
 	} else {
 		return CommentIsEmptyError
 	}
 
-	if operation.Path == "" {
+	if len(operation.RouterProperties) == 0 {
 		return CommentIsEmptyError
 	}
 	return nil
 }
 
+// paramCommentRegexp captures the five positional @Param fields. dataType
+// also accepts a `{object}`/`{array}` marker followed by a model reference,
+// used when paramType is "body"; anything after the quoted description is
+// left for paramAttrRegexp to pick apart.
+var paramCommentRegexp = regexp.MustCompile(`^([\w\.]+)\s+([\w]+)\s+(\{object\}|\{array\}|[\w\.\[\]]+)\s+(?:([\w\.]+)\s+)?([\w]+)\s+"([^"]*)"(.*)$`)
+
+// paramAttrRegexp picks out trailing `key(value)` attributes, e.g.
+// `default(18) minimum(0) enums(child,adult,senior)`.
+var paramAttrRegexp = regexp.MustCompile(`([\w]+)\(([^)]*)\)`)
+
 // Parse params return []string of param properties
 // @Param	queryText		form	      string	  true		        "The email for login"
 // 			[param name]    [param type] [data type]  [is mandatory?]   [Comment]
+// @Param	age		query	      int	  true		        "user age" default(18) minimum(0) maximum(150) example(42) enums(child,adult,senior) format(int32)
+// @Param	req		body	      {object}	model.CreateUser  true		"payload"
 func (operation *Operation) ParseParamComment(commentLine string) error {
 	swaggerParameter := Parameter{}
 	paramString := strings.TrimSpace(commentLine[len("@Param "):])
 
-	re := regexp.MustCompile(`([\w]+)[\s]+([\w]+)[\s]+([\w]+)[\s]+([\w]+)[\s]+"([^"]+)"`)
-
-	if matches := re.FindStringSubmatch(paramString); len(matches) != 6 {
+	matches := paramCommentRegexp.FindStringSubmatch(paramString)
+	if matches == nil {
 		return fmt.Errorf("Can not parse param comment \"%s\", skipped.", paramString)
+	}
+
+	name, paramType, dataType, bodyType, required, description, attrs :=
+		matches[1], matches[2], matches[3], matches[4], matches[5], matches[6], matches[7]
+
+	swaggerParameter.Name = name
+	swaggerParameter.ParamType = paramType
+	swaggerParameter.Required = strings.ToLower(required) == "true"
+	swaggerParameter.Description = description
+
+	if paramType == "body" && (dataType == "{object}" || dataType == "{array}") {
+		if bodyType == "" {
+			return fmt.Errorf("Can not parse param comment \"%s\": missing model after %s", paramString, dataType)
+		}
+		model := NewModel(operation.parser)
+		if err, innerModels := model.ParseModel(operation.qualifyModelType(bodyType), operation.parser.CurrentPackage); err != nil {
+			return err
+		} else {
+			schema := &Schema{Ref: defRef(model.Id)}
+			if dataType == "{array}" {
+				schema = &Schema{Type: "array", Items: schema}
+			}
+			swaggerParameter.Type = model.Id
+			swaggerParameter.DataType = model.Id
+			swaggerParameter.Schema = schema
+
+			operation.models = append(operation.models, model)
+			operation.models = append(operation.models, innerModels...)
+		}
 	} else {
-		//TODO: if type is not simple, then add to Models[]
-		swaggerParameter.Name = matches[1]
-		swaggerParameter.ParamType = matches[2]
-		swaggerParameter.Type = matches[3]
-		swaggerParameter.DataType = matches[3]
-		swaggerParameter.Required = strings.ToLower(matches[4]) == "true"
-		swaggerParameter.Description = matches[5]
+		swaggerParameter.Type = dataType
+		swaggerParameter.DataType = dataType
+	}
 
-		operation.Parameters = append(operation.Parameters, swaggerParameter)
+	for _, attr := range paramAttrRegexp.FindAllStringSubmatch(strings.TrimSpace(attrs), -1) {
+		key, value := attr[1], attr[2]
+		switch key {
+		case "default":
+			swaggerParameter.Default = value
+		case "format":
+			swaggerParameter.Format = value
+		case "example":
+			swaggerParameter.Example = value
+		case "minimum":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("Can not parse param comment \"%s\": minimum(%s) is not a number", paramString, value)
+			}
+			swaggerParameter.Minimum = f
+		case "maximum":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("Can not parse param comment \"%s\": maximum(%s) is not a number", paramString, value)
+			}
+			swaggerParameter.Maximum = f
+		case "enums":
+			for _, e := range strings.Split(value, ",") {
+				swaggerParameter.Enum = append(swaggerParameter.Enum, strings.TrimSpace(e))
+			}
+		}
 	}
 
+	operation.Parameters = append(operation.Parameters, swaggerParameter)
 	return nil
 }
 
+// mimeTypeAliases maps the short tokens accepted by @Accept/@Produce to
+// their full MIME type, mirroring the aliases swag/beego already support.
+var mimeTypeAliases = map[string]string{
+	"json":                  ContentTypeJson,
+	"xml":                   ContentTypeXml,
+	"plain":                 ContentTypePlain,
+	"html":                  ContentTypeHtml,
+	"mpfd":                  "multipart/form-data",
+	"x-www-form-urlencoded": "application/x-www-form-urlencoded",
+	"json-api":              "application/vnd.api+json",
+	"json-stream":           "application/x-json-stream",
+	"octet-stream":          "application/octet-stream",
+	"png":                   "image/png",
+	"jpeg":                  "image/jpeg",
+	"gif":                   "image/gif",
+}
+
+// rawMimeTypeRegexp validates an arbitrary `type/subtype` MIME string, e.g.
+// "application/vnd.myco.v2+json", that isn't in mimeTypeAliases.
+var rawMimeTypeRegexp = regexp.MustCompile(`^[^/\s]+/[^/\s]+$`)
+
+// resolveMimeType maps an @Accept/@Produce token to a MIME type, accepting
+// both the short aliases and any raw "type/subtype" string.
+func resolveMimeType(token string) (string, error) {
+	if mime, ok := mimeTypeAliases[token]; ok {
+		return mime, nil
+	}
+	if rawMimeTypeRegexp.MatchString(token) {
+		return token, nil
+	}
+	return "", fmt.Errorf("Can not resolve mime type \"%s\", skipped.", token)
+}
+
 // @Accept  json
 func (operation *Operation) ParseAcceptComment(commentLine string) error {
-	accepts := strings.Split(strings.TrimSpace(strings.TrimSpace(commentLine[len("@Accept"):])), ",")
+	accepts := strings.Split(strings.TrimSpace(commentLine[len("@Accept"):]), ",")
 	for _, a := range accepts {
-		switch a {
-		case "json":
-			operation.Consumes = append(operation.Consumes, ContentTypeJson)
-			operation.Produces = append(operation.Produces, ContentTypeJson)
-		case "xml":
-			operation.Consumes = append(operation.Consumes, ContentTypeXml)
-			operation.Produces = append(operation.Produces, ContentTypeXml)
-		case "plain":
-			operation.Consumes = append(operation.Consumes, ContentTypePlain)
-			operation.Produces = append(operation.Produces, ContentTypePlain)
-		case "html":
-			operation.Consumes = append(operation.Consumes, ContentTypeHtml)
-			operation.Produces = append(operation.Produces, ContentTypeHtml)
+		mime, err := resolveMimeType(strings.TrimSpace(a))
+		if err != nil {
+			return err
+		}
+		operation.Consumes = append(operation.Consumes, mime)
+	}
+	return nil
+}
+
+// @Produce  json
+func (operation *Operation) ParseProduceComment(commentLine string) error {
+	produces := strings.Split(strings.TrimSpace(commentLine[len("@Produce"):]), ",")
+	for _, p := range produces {
+		mime, err := resolveMimeType(strings.TrimSpace(p))
+		if err != nil {
+			return err
 		}
+		operation.Produces = append(operation.Produces, mime)
 	}
 	return nil
 }
 
+// @Security ApiKeyAuth
+// @Security OAuth2Implicit[read,write]
+//
+// The referenced name is the name of a @SecurityDefinition.* declared at
+// the package/root level; it is recorded as given and not checked against
+// the Parser's SecurityDefinitions.
+func (operation *Operation) ParseSecurityComment(commentLine string) error {
+	sourceString := strings.TrimSpace(commentLine[len("@Security"):])
+
+	name := sourceString
+	var scopes []string
+	if start := strings.Index(sourceString, "["); start != -1 {
+		end := strings.Index(sourceString, "]")
+		if end == -1 || end < start {
+			return fmt.Errorf("Can not parse security comment \"%s\", skipped.", commentLine)
+		}
+		name = strings.TrimSpace(sourceString[:start])
+		for _, scope := range strings.Split(sourceString[start+1:end], ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	operation.Authorizations = append(operation.Authorizations, Authorization{
+		Name:   name,
+		Scopes: scopes,
+	})
+	return nil
+}
+
 // @router /customer/get-wishlist/{wishlist_id} [get]
+// @router /customer/get-wishlist/{wishlist_id} [get,deprecated]
 func (operation *Operation) ParseRouterComment(commentLine string) error {
 	sourceString := strings.TrimSpace(commentLine[len("@router"):])
 
@@ -151,17 +420,49 @@ func (operation *Operation) ParseRouterComment(commentLine string) error {
 		return fmt.Errorf("Can not parse router comment \"%s\", skipped.", commentLine)
 	}
 
-	operation.Path = matches[1]
-	operation.HttpMethod = strings.ToUpper(matches[2])
+	deprecated := operation.deprecated
+	method := matches[2]
+	if idx := strings.Index(method, ","); idx != -1 {
+		for _, token := range strings.Split(method[idx+1:], ",") {
+			if strings.ToLower(strings.TrimSpace(token)) == "deprecated" {
+				deprecated = true
+			}
+		}
+		method = method[:idx]
+	}
+
+	route := RouteProperties{
+		HTTPMethod: strings.ToUpper(strings.TrimSpace(method)),
+		Path:       matches[1],
+		Deprecated: deprecated,
+	}
+	operation.RouterProperties = append(operation.RouterProperties, route)
+	if len(operation.RouterProperties) == 1 {
+		operation.HttpMethod = route.HTTPMethod
+		operation.Path = route.Path
+	}
 	return nil
 }
 
 // @Success 200 {object} model.OrderRow "Error message, if code != 200"
+// @Success 200 {object} model.OrderRow file
+// @Success 200 {object} model.OrderRow "Error message, if code != 200" file
 func (operation *Operation) ParseResponseComment(commentLine string) error {
+	sourceString := strings.TrimSpace(commentLine)
+
+	loadExampleFromFile := false
+	if fields := strings.Fields(sourceString); len(fields) > 1 && fields[len(fields)-1] == "file" {
+		loadExampleFromFile = true
+		sourceString = strings.TrimSpace(strings.Join(fields[:len(fields)-1], " "))
+	}
+	if !strings.Contains(sourceString, `"`) {
+		sourceString += ` ""`
+	}
+
 	re := regexp.MustCompile(`([\d]+)[\s]+([\w\{\}]+)[\s]+([\w\.\/]+)[^"]*(.*)?`)
 	var matches []string
 
-	if matches = re.FindStringSubmatch(commentLine); len(matches) != 5 {
+	if matches = re.FindStringSubmatch(sourceString); len(matches) != 5 {
 		return fmt.Errorf("Can not parse response comment \"%s\", skipped.", commentLine)
 	}
 
@@ -174,12 +475,18 @@ func (operation *Operation) ParseResponseComment(commentLine string) error {
 
 	if matches[2] == "{object}" || matches[2] == "{array}" {
 		model := NewModel(operation.parser)
-		response.ResponseModel = matches[3]
+		response.ResponseModel = operation.qualifyModelType(matches[3])
 		if err, innerModels := model.ParseModel(response.ResponseModel, operation.parser.CurrentPackage); err != nil {
 			return err
 		} else {
 			response.ResponseModel = model.Id
-			if matches[1] == "{array}" {
+			if matches[2] == "{array}" {
+				response.IsArray = true
+				if IsBasicType(model.Id) {
+					response.Items = OperationItems{Type: model.Id}
+				} else {
+					response.Items = OperationItems{Ref: model.Id}
+				}
 				operation.SetItemsType(model.Id)
 				operation.Type = "array"
 			} else {
@@ -192,6 +499,49 @@ func (operation *Operation) ParseResponseComment(commentLine string) error {
 	}
 	response.Message = strings.Trim(matches[4], "\"")
 
+	if loadExampleFromFile {
+		if err := operation.loadResponseExampleFile(&response); err != nil {
+			return err
+		}
+	}
+
 	operation.ResponseMessages = append(operation.ResponseMessages, response)
 	return nil
 }
+
+// loadResponseExampleFile reads a JSON or YAML fixture named after the
+// operation's Nickname (falling back to Summary) from the Parser's
+// configured codeExampleFilesDir and attaches it to response.Examples, so
+// large realistic payloads can live outside Go source comments.
+func (operation *Operation) loadResponseExampleFile(response *ResponseMessage) error {
+	dir := operation.parser.CodeExampleFilesDir
+	if dir == "" {
+		return fmt.Errorf("@Success/@Failure references a file example but no codeExampleFilesDir is configured")
+	}
+
+	name := operation.Nickname
+	if name == "" {
+		name = operation.Summary
+	}
+
+	var example interface{}
+	for _, ext := range []string{".json", ".yaml", ".yml"} {
+		path := filepath.Join(dir, name+ext)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if ext == ".json" {
+			err = json.Unmarshal(data, &example)
+		} else {
+			err = yaml.Unmarshal(data, &example)
+		}
+		if err != nil {
+			return fmt.Errorf("Can not parse example file \"%s\": %s", path, err)
+		}
+		response.Examples = example
+		return nil
+	}
+
+	return fmt.Errorf("Can not find example file for \"%s\" under %s", name, dir)
+}
@@ -0,0 +1,267 @@
+package parser
+
+import (
+	"go/ast"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseResponseCommentFileMarker(t *testing.T) {
+	cases := []struct {
+		name           string
+		commentLine    string
+		wantModel      string
+		wantLoadedFile bool
+	}{
+		{
+			name:           "no description, type name ends in file but isn't the marker",
+			commentLine:    "200 {object} model.Profile",
+			wantModel:      "model.Profile",
+			wantLoadedFile: false,
+		},
+		{
+			name:           "trailing file token with no description",
+			commentLine:    "200 {object} model.OrderRow file",
+			wantModel:      "model.OrderRow",
+			wantLoadedFile: true,
+		},
+		{
+			name:           "trailing file token after a quoted description",
+			commentLine:    `200 {object} model.OrderRow "ok" file`,
+			wantModel:      "model.OrderRow",
+			wantLoadedFile: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			operation := NewOperation(&Parser{CurrentPackage: "test"}, "test")
+			err := operation.ParseResponseComment(tc.commentLine)
+			if tc.wantLoadedFile {
+				if err == nil {
+					t.Fatalf("expected an error since no codeExampleFilesDir is configured, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(operation.ResponseMessages) != 1 || operation.ResponseMessages[0].ResponseModel != tc.wantModel {
+				t.Fatalf("got response messages %#v, want model %q", operation.ResponseMessages, tc.wantModel)
+			}
+		})
+	}
+}
+
+func TestPackageNameInDirUsesDeclaredNameNotDirBasename(t *testing.T) {
+	// "gopkg.in/yaml.v2" declares package "yaml", not "yaml.v2" - the
+	// directory basename the real module lives under doesn't match the
+	// alias source refers to it by, so packageAlias can't just take the
+	// last path segment and must read the declared name via go/build.
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "yaml.go"), []byte("package yaml\n"), 0o644); err != nil {
+		t.Fatalf("failed to write fixture file: %v", err)
+	}
+
+	got, err := packageNameInDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "yaml" {
+		t.Fatalf("packageNameInDir(%q) = %q, want %q", dir, got, "yaml")
+	}
+}
+
+func TestQualifyModelTypeUsesDeclaredPackageAlias(t *testing.T) {
+	operation := NewOperation(&Parser{CurrentPackage: "test"}, "test")
+	operation.imports = map[string]string{
+		// Stands in for what SetPackageImports/packageAlias would have
+		// resolved "gopkg.in/yaml.v2" to, since qualifyModelType itself
+		// only needs a populated imports map, not the go/build lookup;
+		// that lookup is covered by TestPackageNameInDirUsesDeclaredNameNotDirBasename.
+		"yaml": "gopkg.in/yaml.v2",
+	}
+
+	got := operation.qualifyModelType("yaml.Node")
+	want := "gopkg.in/yaml.v2.Node"
+	if got != want {
+		t.Fatalf("qualifyModelType(%q) = %q, want %q", "yaml.Node", got, want)
+	}
+
+	// An unknown alias is left untouched so the CurrentPackage fallback in
+	// model.ParseModel still applies.
+	if got := operation.qualifyModelType("other.Node"); got != "other.Node" {
+		t.Fatalf("qualifyModelType(%q) = %q, want unchanged", "other.Node", got)
+	}
+}
+
+func TestDefRefEscapesSlashesPerJSONPointer(t *testing.T) {
+	const prefix = "#/definitions/"
+	ref := defRef("gopkg.in/yaml.v2.Node")
+	if !strings.HasPrefix(ref, prefix) {
+		t.Fatalf("defRef(%q) = %q, want it to start with %q", "gopkg.in/yaml.v2.Node", ref, prefix)
+	}
+	// Only the "#/definitions/" separator may contain an unescaped "/";
+	// everything from the model id itself must be escaped to "~1" or the
+	// $ref would be read as a path of nested object lookups.
+	segment := strings.TrimPrefix(ref, prefix)
+	if strings.Contains(segment, "/") {
+		t.Fatalf("defRef(%q) = %q, want no literal '/' after %q", "gopkg.in/yaml.v2.Node", ref, prefix)
+	}
+	if !strings.Contains(segment, "~1") {
+		t.Fatalf("defRef(%q) = %q, want escaped '/' as '~1'", "gopkg.in/yaml.v2.Node", ref)
+	}
+}
+
+func TestParseRouterCommentDeprecatedBackfillsAndForwardfills(t *testing.T) {
+	operation := NewOperation(&Parser{CurrentPackage: "test"}, "test")
+
+	if err := operation.ParseRouterComment("@router /a [get]"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := operation.ParseRouterComment("@router /b [post]"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	commentList := &ast.CommentGroup{List: []*ast.Comment{{Text: "// @Deprecated"}}}
+	if err := operation.ParseComment(commentList); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, route := range operation.RouterProperties {
+		if !route.Deprecated {
+			t.Fatalf("route %#v not back-filled as deprecated", route)
+		}
+	}
+
+	if err := operation.ParseRouterComment("@router /c [put]"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	last := operation.RouterProperties[len(operation.RouterProperties)-1]
+	if !last.Deprecated {
+		t.Fatalf("route registered after @Deprecated was not forward-filled: %#v", last)
+	}
+}
+
+func TestParseSecurityCommentBracketScopes(t *testing.T) {
+	cases := []struct {
+		name        string
+		commentLine string
+		wantName    string
+		wantScopes  []string
+		wantErr     bool
+	}{
+		{
+			name:        "bare name, no scopes",
+			commentLine: "@Security ApiKeyAuth",
+			wantName:    "ApiKeyAuth",
+		},
+		{
+			name:        "name with bracketed scopes",
+			commentLine: "@Security OAuth2Implicit[read,write]",
+			wantName:    "OAuth2Implicit",
+			wantScopes:  []string{"read", "write"},
+		},
+		{
+			name:        "unterminated bracket is an error",
+			commentLine: "@Security OAuth2Implicit[read,write",
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			operation := NewOperation(&Parser{CurrentPackage: "test"}, "test")
+			err := operation.ParseSecurityComment(tc.commentLine)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(operation.Authorizations) != 1 {
+				t.Fatalf("got %d authorizations, want 1", len(operation.Authorizations))
+			}
+			got := operation.Authorizations[0]
+			if got.Name != tc.wantName {
+				t.Fatalf("got name %q, want %q", got.Name, tc.wantName)
+			}
+			if len(got.Scopes) != len(tc.wantScopes) {
+				t.Fatalf("got scopes %#v, want %#v", got.Scopes, tc.wantScopes)
+			}
+			for i, scope := range tc.wantScopes {
+				if got.Scopes[i] != scope {
+					t.Fatalf("got scopes %#v, want %#v", got.Scopes, tc.wantScopes)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveMimeType(t *testing.T) {
+	cases := []struct {
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{token: "json", want: ContentTypeJson},
+		{token: "xml", want: ContentTypeXml},
+		{token: "application/vnd.myco.v2+json", want: "application/vnd.myco.v2+json"},
+		{token: "not-a-mime-type", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.token, func(t *testing.T) {
+			got, err := resolveMimeType(tc.token)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("resolveMimeType(%q) = %q, want %q", tc.token, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseAcceptCommentSplitsOnComma(t *testing.T) {
+	operation := NewOperation(&Parser{CurrentPackage: "test"}, "test")
+	if err := operation.ParseAcceptComment("@Accept json,xml"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{ContentTypeJson, ContentTypeXml}
+	if len(operation.Consumes) != len(want) {
+		t.Fatalf("got consumes %#v, want %#v", operation.Consumes, want)
+	}
+	for i, mime := range want {
+		if operation.Consumes[i] != mime {
+			t.Fatalf("got consumes %#v, want %#v", operation.Consumes, want)
+		}
+	}
+}
+
+func TestResponseSchemaIsPerResponseNotOperationWide(t *testing.T) {
+	ok := ResponseMessage{Code: 200, ResponseModel: "model.OK"}
+	failure := ResponseMessage{Code: 400, ResponseModel: "model.ErrorResp"}
+
+	okSchema := responseSchema(ok)
+	failureSchema := responseSchema(failure)
+
+	if okSchema == nil || okSchema.Ref != defRef("model.OK") {
+		t.Fatalf("responseSchema(200) = %#v, want ref to model.OK", okSchema)
+	}
+	if failureSchema == nil || failureSchema.Ref != defRef("model.ErrorResp") {
+		t.Fatalf("responseSchema(400) = %#v, want ref to model.ErrorResp", failureSchema)
+	}
+	if okSchema.Ref == failureSchema.Ref {
+		t.Fatalf("200 and 400 responses resolved to the same schema %q", okSchema.Ref)
+	}
+}